@@ -0,0 +1,20 @@
+// Package rcon implements the RCON ("remote console") protocol used by
+// Source-engine games and compatible servers, for both clients (Dial,
+// RemoteConsole) and servers (Server, Handler).
+//
+// # Supported protocols
+//
+// The wire dialect is pluggable via Dialer.Protocol / Server.Protocol:
+//
+//   - SourceProtocol (default): the Valve Source RCON protocol, used by
+//     Source-engine games and most of their clones (Rust, ARK, ...).
+//   - MinecraftProtocol: Minecraft's RCON dialect.
+//
+// BattlEye RCON and 7 Days To Die's console are NOT implemented and aren't
+// planned as a Protocol value: BattlEye runs over UDP with its own
+// CRC32-checked framing and sequenced keep-alives, and 7DTD's console is a
+// line-oriented telnet prompt, so neither fits the length-prefixed-TCP-packet
+// shape the Protocol interface assumes. Supporting either needs a different
+// transport underneath RemoteConsole/Server, not just a new Protocol value;
+// tracked as follow-up work, not partially started anywhere in this package.
+package rcon
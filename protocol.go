@@ -0,0 +1,155 @@
+package rcon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// Protocol encodes and decodes the packets of a particular RCON dialect.
+// RemoteConsole dispatches all framing through the Protocol set on its
+// Dialer, which is what lets one client type speak to Source-engine
+// servers, Minecraft, and other variants without any of the higher-level
+// Dial/Exec/Write code having to know the wire differences.
+type Protocol interface {
+	// WritePacket writes a single packet carrying id, typ and body to w,
+	// including whatever size prefix and terminators the dialect requires.
+	WritePacket(w io.Writer, id, typ int32, body []byte) error
+
+	// ReadPacket reads and decodes a single packet from r.
+	ReadPacket(r io.Reader) (id, typ int32, body []byte, err error)
+}
+
+// SourceProtocol implements the Valve Source RCON protocol:
+// https://developer.valvesoftware.com/wiki/Source_RCON_Protocol
+//
+// It's the Protocol Dial/DialContext use, and is correct for all
+// Source-engine games (CS, TF2, Garry's Mod, ...) as well as servers that
+// faithfully clone it (Rust, ARK, and most others).
+var SourceProtocol Protocol = sourceProtocol{}
+
+type sourceProtocol struct{}
+
+func (sourceProtocol) WritePacket(w io.Writer, id, typ int32, body []byte) error {
+	if len(body) > 1024-10 {
+		return ErrCommandTooLong
+	}
+
+	buffer := bytes.NewBuffer(make([]byte, 0, minPackageSize+fieldPackageSize+len(body)))
+
+	// packet size
+	binary.Write(buffer, binary.LittleEndian, int32(minPackageSize+len(body)))
+	// request id
+	binary.Write(buffer, binary.LittleEndian, id)
+	// package type
+	binary.Write(buffer, binary.LittleEndian, typ)
+	// body (null terminated)
+	buffer.Write(body)
+	binary.Write(buffer, binary.LittleEndian, byte(0))
+	// string 2 (null terminated)
+	// we don't have a use for string 2
+	binary.Write(buffer, binary.LittleEndian, byte(0))
+
+	_, err := w.Write(buffer.Bytes())
+	return err
+}
+
+func (sourceProtocol) ReadPacket(r io.Reader) (int32, int32, []byte, error) {
+	var sizeBuf [fieldPackageSize]byte
+	if _, err := io.ReadFull(r, sizeBuf[:]); err != nil {
+		return 0, 0, nil, err
+	}
+
+	size := int32(binary.LittleEndian.Uint32(sizeBuf[:]))
+	if size < minPackageSize {
+		return 0, 0, nil, ErrUnexpectedFormat
+	}
+	if int(size) > maxPackageSize {
+		return 0, 0, nil, ErrResponseTooLong
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, 0, nil, err
+	}
+
+	return decodeIDTypeBody(data)
+}
+
+// minecraftMinPackageSize is sourceProtocol's minPackageSize minus the
+// second, unused null terminator Minecraft servers don't send.
+const minecraftMinPackageSize = fieldIDSize + fieldTypeSize + fieldEndSize
+
+// MinecraftProtocol implements the RCON dialect spoken by Minecraft's
+// server. It differs from SourceProtocol only in the trailing padding: it
+// writes (and expects) a single null-terminated body instead of the second,
+// always-empty string Source packets carry.
+var MinecraftProtocol Protocol = minecraftProtocol{}
+
+type minecraftProtocol struct{}
+
+func (minecraftProtocol) WritePacket(w io.Writer, id, typ int32, body []byte) error {
+	if len(body) > 1024-10 {
+		return ErrCommandTooLong
+	}
+
+	buffer := bytes.NewBuffer(make([]byte, 0, minecraftMinPackageSize+fieldPackageSize+len(body)))
+
+	// packet size
+	binary.Write(buffer, binary.LittleEndian, int32(minecraftMinPackageSize+len(body)))
+	// request id
+	binary.Write(buffer, binary.LittleEndian, id)
+	// package type
+	binary.Write(buffer, binary.LittleEndian, typ)
+	// body (null terminated, no second string)
+	buffer.Write(body)
+	binary.Write(buffer, binary.LittleEndian, byte(0))
+
+	_, err := w.Write(buffer.Bytes())
+	return err
+}
+
+func (minecraftProtocol) ReadPacket(r io.Reader) (int32, int32, []byte, error) {
+	var sizeBuf [fieldPackageSize]byte
+	if _, err := io.ReadFull(r, sizeBuf[:]); err != nil {
+		return 0, 0, nil, err
+	}
+
+	size := int32(binary.LittleEndian.Uint32(sizeBuf[:]))
+	if size < minecraftMinPackageSize {
+		return 0, 0, nil, ErrUnexpectedFormat
+	}
+	if int(size) > maxPackageSize {
+		return 0, 0, nil, ErrResponseTooLong
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, 0, nil, err
+	}
+
+	return decodeIDTypeBody(data)
+}
+
+// decodeIDTypeBody parses the id/type/null-terminated-body layout shared by
+// SourceProtocol and MinecraftProtocol out of a single already-read packet.
+func decodeIDTypeBody(data []byte) (int32, int32, []byte, error) {
+	var id, typ int32
+	b := bytes.NewBuffer(data)
+	binary.Read(b, binary.LittleEndian, &id)
+	binary.Read(b, binary.LittleEndian, &typ)
+
+	body, err := b.ReadBytes(0)
+	if err != nil && err != io.EOF {
+		return 0, 0, nil, err
+	}
+	if err == nil {
+		// if we didn't hit EOF, we have a null byte to remove
+		body = body[:len(body)-1]
+	}
+	return id, typ, body, nil
+}
+
+// BattlEye and 7 Days To Die's telnet prompt aren't implemented as a
+// Protocol here; see the package doc comment (doc.go) for why and for their
+// follow-up status.
@@ -0,0 +1,48 @@
+package rcon
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestProtocolRoundTrip(t *testing.T) {
+	protocols := map[string]Protocol{
+		"source":    SourceProtocol,
+		"minecraft": MinecraftProtocol,
+	}
+
+	for name, p := range protocols {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := p.WritePacket(&buf, 42, typeExecCommand, []byte("status")); err != nil {
+				t.Fatal(err)
+			}
+
+			id, typ, body, err := p.ReadPacket(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id != 42 {
+				t.Fatalf("id = %d, want 42", id)
+			}
+			if typ != typeExecCommand {
+				t.Fatalf("typ = %d, want %d", typ, typeExecCommand)
+			}
+			if string(body) != "status" {
+				t.Fatalf("body = %q, want %q", body, "status")
+			}
+		})
+	}
+}
+
+func TestDialerDefaultsToSourceProtocol(t *testing.T) {
+	var d Dialer
+	if d.protocol() != SourceProtocol {
+		t.Fatal("zero-value Dialer should default to SourceProtocol")
+	}
+
+	d.Protocol = MinecraftProtocol
+	if d.protocol() != MinecraftProtocol {
+		t.Fatal("Dialer.protocol() should return the configured Protocol")
+	}
+}
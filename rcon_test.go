@@ -2,9 +2,14 @@ package rcon
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
+	"fmt"
+	"io"
 	"net"
+	"sync"
 	"testing"
+	"time"
 )
 
 func startTestServer(fn func(net.Conn, *bytes.Buffer)) (string, error) {
@@ -51,6 +56,30 @@ func startTestServer(fn func(net.Conn, *bytes.Buffer)) (string, error) {
 	return listener.Addr().String(), nil
 }
 
+// readClientPacket reads and decodes a single packet sent by the client,
+// for tests that need to react to the client's actual request ID (e.g.
+// ExecMulti, which addresses its terminator packet to the same ID as the
+// command it follows).
+func readClientPacket(conn net.Conn) (requestID, cmdType int32, body []byte, err error) {
+	buf := make([]byte, maxPackageSize)
+	_, err = conn.Read(buf)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	var packetSize int32
+	b := bytes.NewBuffer(buf)
+	binary.Read(b, binary.LittleEndian, &packetSize)
+	binary.Read(b, binary.LittleEndian, &requestID)
+	binary.Read(b, binary.LittleEndian, &cmdType)
+	body, err = b.ReadBytes(0x00)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	body = body[:len(body)-1]
+	return requestID, cmdType, body, nil
+}
+
 func buildStartOfPackage(size int, requestID, responseType int32, body []byte) *bytes.Buffer {
 	b := bytes.NewBuffer([]byte{})
 	binary.Write(b, binary.LittleEndian, int32(minPackageSize+size))
@@ -188,3 +217,234 @@ func TestMultipacket(t *testing.T) {
 	})
 
 }
+
+func TestExecMulti(t *testing.T) {
+	t.Run("reassembles a response split across packets", func(t *testing.T) {
+		addr, err := startTestServer(func(c net.Conn, b *bytes.Buffer) {
+			reqid, _, _, err := readClientPacket(c)
+			if err != nil {
+				return
+			}
+			// The client's terminator packet (same request ID, empty
+			// body) may already be sitting unread in the socket buffer
+			// behind the command packet above; we don't need its
+			// contents, only the request ID the command carried.
+
+			c.Write(buildPackage(reqid, typeResponseValue, []byte("hello ")).Bytes())
+			c.Write(buildPackage(reqid, typeResponseValue, []byte("world")).Bytes())
+			c.Write(buildPackage(reqid, typeResponseValue, []byte{}).Bytes())
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rc, err := Dial(addr, "blerg")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+
+		str, err := rc.ExecMulti("status")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if str != "hello world" {
+			t.Fatalf("unexpected response: %q", str)
+		}
+	})
+
+	t.Run("stops at an Unknown request terminator", func(t *testing.T) {
+		addr, err := startTestServer(func(c net.Conn, b *bytes.Buffer) {
+			reqid, _, _, err := readClientPacket(c)
+			if err != nil {
+				return
+			}
+
+			c.Write(buildPackage(reqid, typeResponseValue, []byte("body")).Bytes())
+			c.Write(buildPackage(reqid, typeResponseValue, []byte("Unknown request 12345678")).Bytes())
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rc, err := Dial(addr, "blerg")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+
+		str, err := rc.ExecMulti("status")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if str != "body" {
+			t.Fatalf("unexpected response: %q", str)
+		}
+	})
+}
+
+func TestReadStream(t *testing.T) {
+	addr, err := startTestServer(func(c net.Conn, b *bytes.Buffer) {
+		reqid, _, _, err := readClientPacket(c)
+		if err != nil {
+			return
+		}
+
+		c.Write(buildPackage(reqid, typeResponseValue, []byte("chunk one ")).Bytes())
+		c.Write(buildPackage(reqid, typeResponseValue, []byte("chunk two")).Bytes())
+		c.Write(buildPackage(reqid, typeResponseValue, []byte{}).Bytes())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := Dial(addr, "blerg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	_, body, err := rc.ReadStream("status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "chunk one chunk two" {
+		t.Fatalf("unexpected response: %q", data)
+	}
+}
+
+// TestCloseUnblocksRead reproduces a race where Close can race demux's
+// handoff of an unclaimed packet to r.incoming: if demux is parked in
+// `select { case r.incoming <- res: case <-r.closeCh: }` when Close runs,
+// it returns via the closeCh case without ever calling failAll, so
+// r.incoming is never closed. Read must still return instead of blocking
+// forever, by also selecting on r.closeCh.
+func TestCloseUnblocksRead(t *testing.T) {
+	addr, err := startTestServer(func(c net.Conn, b *bytes.Buffer) {
+		// An unsolicited response: nothing is waiting on an ExecContext
+		// pending entry for it, so demux tries to hand it to Read via
+		// r.incoming and parks there until something reads or closeCh
+		// closes.
+		c.Write(buildPackage(999, typeResponseValue, []byte("unsolicited")).Bytes())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := Dial(addr, "blerg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Give demux time to read the unsolicited packet and park on the
+	// r.incoming send before Close races it.
+	time.Sleep(50 * time.Millisecond)
+
+	if err := rc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		rc.Read()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Read did not return after Close raced an in-flight unclaimed packet")
+	}
+}
+
+// TestReadContextTimeout checks that ReadContext bounds its wait via ctx,
+// since plain Read no longer applies any implicit read deadline.
+func TestReadContextTimeout(t *testing.T) {
+	// Keep the server connection open (and silent) for the life of the
+	// test, so demux has nothing to report and ReadContext's timeout is
+	// what ends the wait rather than the connection closing.
+	stop := make(chan struct{})
+	defer close(stop)
+
+	addr, err := startTestServer(func(c net.Conn, b *bytes.Buffer) {
+		<-stop
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := Dial(addr, "blerg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, _, err = rc.ReadContext(ctx)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestDialContext(t *testing.T) {
+	addr, err := startTestServer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := DialContext(context.Background(), addr, "blerg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+}
+
+// TestExecContextConcurrent dials against a real rcon.Server (so every
+// command gets a response addressed to the request ID it was sent under)
+// and hammers ExecContext from many goroutines at once. It's meant to catch
+// request-ID allocation races in encodeCmd: if two concurrent callers were
+// ever assigned the same ID, one of them would clobber the other's pending
+// entry and get back the wrong response (or hang until ctx's deadline).
+func TestExecContextConcurrent(t *testing.T) {
+	s := &Server{
+		Authenticator: func(password string) bool { return password == "blerg" },
+		Handler: HandlerFunc(func(w ResponseWriter, req *Request) {
+			w.Write([]byte("echo: " + req.Body))
+		}),
+	}
+	addr := startRCONServer(t, s)
+
+	rc, err := Dial(addr, "blerg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	const n = 64
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			cmd := fmt.Sprintf("cmd-%d", i)
+			resp, err := rc.ExecContext(context.Background(), cmd)
+			if err != nil {
+				t.Errorf("ExecContext(%q): %v", cmd, err)
+				return
+			}
+			want := "echo: " + cmd
+			if resp != want {
+				t.Errorf("ExecContext(%q) = %q, want %q", cmd, resp, want)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
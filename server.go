@@ -0,0 +1,193 @@
+package rcon
+
+import (
+	"bufio"
+	"log"
+	"net"
+)
+
+// Request is a single SERVERDATA_EXECCOMMAND sent by an authenticated
+// client.
+type Request struct {
+	// ID is the request ID the client sent the command under; responses
+	// must be written under the same ID for the client to correlate them.
+	ID int
+
+	// Body is the command text.
+	Body string
+
+	// RemoteAddr is the client's network address.
+	RemoteAddr net.Addr
+}
+
+// ResponseWriter lets a Handler send one or more SERVERDATA_RESPONSE_VALUE
+// packets back for a Request. A response larger than the protocol's packet
+// size limit should be sent across multiple Write calls; the server takes
+// care of the empty-packet echo that lets clients like ExecMulti/ReadStream
+// detect where such a multi-packet response ends.
+type ResponseWriter interface {
+	Write(body []byte) (int, error)
+}
+
+// Handler responds to a Request from an authenticated client.
+type Handler interface {
+	ServeRCON(w ResponseWriter, req *Request)
+}
+
+// HandlerFunc adapts a plain function to a Handler.
+type HandlerFunc func(w ResponseWriter, req *Request)
+
+// ServeRCON calls f(w, req).
+func (f HandlerFunc) ServeRCON(w ResponseWriter, req *Request) {
+	f(w, req)
+}
+
+// Authenticator validates the password a client sends during the
+// SERVERDATA_AUTH handshake. Returning false fails the auth for that client,
+// matching how Source servers respond to a bad password.
+type Authenticator func(password string) bool
+
+// Server is a listening RCON endpoint. It speaks the same Protocol
+// (WritePacket/ReadPacket) the client half of this package uses, so the
+// wire framing and its edge cases (the empty-packet multi-response
+// terminator chief among them) are fixed in exactly one place for both
+// ends.
+type Server struct {
+	// Authenticator validates each connecting client's password. Required:
+	// the zero value rejects every client.
+	Authenticator Authenticator
+
+	// Handler dispatches authenticated commands. Required: the zero value
+	// answers every command with an empty response.
+	Handler Handler
+
+	// Protocol selects the wire dialect to speak. nil means SourceProtocol.
+	Protocol Protocol
+}
+
+func (s *Server) protocol() Protocol {
+	if s.Protocol != nil {
+		return s.Protocol
+	}
+	return SourceProtocol
+}
+
+func (s *Server) handler() Handler {
+	if s.Handler != nil {
+		return s.Handler
+	}
+	return HandlerFunc(func(w ResponseWriter, _ *Request) { w.Write(nil) })
+}
+
+// ListenAndServe listens on addr and serves RCON connections until Accept
+// returns an error. It closes the listener before returning.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l, handling each on its own goroutine, until
+// Accept returns an error.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	protocol := s.protocol()
+	reader := bufio.NewReaderSize(conn, maxPackageSize)
+
+	if !s.authenticate(conn, reader, protocol) {
+		return
+	}
+
+	for {
+		id, typ, body, err := protocol.ReadPacket(reader)
+		if err != nil {
+			return
+		}
+		if typ != typeExecCommand {
+			continue
+		}
+
+		// writeMulti's terminator is an empty SERVERDATA_EXECCOMMAND under
+		// the same request ID as the command it follows, never a command a
+		// Handler should see; echoing it back as an empty
+		// SERVERDATA_RESPONSE_VALUE is what lets ExecMulti/ReadStream on
+		// the other end detect where a response split across more than one
+		// packet ends.
+		if len(body) == 0 {
+			if err := protocol.WritePacket(conn, id, typeResponseValue, nil); err != nil {
+				return
+			}
+			continue
+		}
+
+		w := &responseWriter{conn: conn, protocol: protocol, id: id}
+		req := &Request{ID: int(id), Body: string(body), RemoteAddr: conn.RemoteAddr()}
+		if !s.serveRequest(w, req) {
+			return
+		}
+	}
+}
+
+// serveRequest runs the Handler for a single Request, recovering from any
+// panic so that one connection's malformed or adversarial input (this
+// package is explicitly meant for protocol-fuzzing harnesses as well as
+// ordinary servers) can't take down every other connection in the process.
+// It returns false if the connection should be closed.
+func (s *Server) serveRequest(w ResponseWriter, req *Request) (ok bool) {
+	ok = true
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("rcon: handler panic for request %d from %s: %v", req.ID, req.RemoteAddr, r)
+			ok = false
+		}
+	}()
+	s.handler().ServeRCON(w, req)
+	return ok
+}
+
+// authenticate reads the SERVERDATA_AUTH packet a client must send first,
+// checks its password against s.Authenticator, and replies with the auth
+// response. It returns whether the client is authenticated and the
+// connection should proceed to serving commands.
+func (s *Server) authenticate(conn net.Conn, reader *bufio.Reader, protocol Protocol) bool {
+	id, typ, body, err := protocol.ReadPacket(reader)
+	if err != nil || typ != typeAuth {
+		return false
+	}
+
+	if s.Authenticator == nil || !s.Authenticator(string(body)) {
+		protocol.WritePacket(conn, -1, typeAuthResponse, nil)
+		return false
+	}
+
+	return protocol.WritePacket(conn, id, typeAuthResponse, nil) == nil
+}
+
+// responseWriter implements ResponseWriter over a single connection and
+// request ID.
+type responseWriter struct {
+	conn     net.Conn
+	protocol Protocol
+	id       int32
+}
+
+func (w *responseWriter) Write(body []byte) (int, error) {
+	if err := w.protocol.WritePacket(w.conn, w.id, typeResponseValue, body); err != nil {
+		return 0, err
+	}
+	return len(body), nil
+}
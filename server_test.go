@@ -0,0 +1,147 @@
+package rcon
+
+import (
+	"net"
+	"testing"
+)
+
+func startRCONServer(t *testing.T, s *Server) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go s.Serve(l)
+
+	return l.Addr().String()
+}
+
+func TestServerAuth(t *testing.T) {
+	s := &Server{
+		Authenticator: func(password string) bool { return password == "blerg" },
+	}
+	addr := startRCONServer(t, s)
+
+	t.Run("correct password", func(t *testing.T) {
+		rc, err := Dial(addr, "blerg")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		_, err := Dial(addr, "nope")
+		if err != ErrAuthFailed {
+			t.Fatalf("err = %v, want ErrAuthFailed", err)
+		}
+	})
+}
+
+func TestServerExecCommand(t *testing.T) {
+	s := &Server{
+		Authenticator: func(password string) bool { return password == "blerg" },
+		Handler: HandlerFunc(func(w ResponseWriter, req *Request) {
+			w.Write([]byte("you said: " + req.Body))
+		}),
+	}
+	addr := startRCONServer(t, s)
+
+	rc, err := Dial(addr, "blerg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	reqid, err := rc.Write("hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, respID, err := rc.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp != "you said: hello" {
+		t.Fatalf("resp = %q, want %q", resp, "you said: hello")
+	}
+	if respID != reqid {
+		t.Fatalf("respID = %d, want %d", respID, reqid)
+	}
+}
+
+func TestServerExecMulti(t *testing.T) {
+	s := &Server{
+		Authenticator: func(password string) bool { return password == "blerg" },
+		Handler: HandlerFunc(func(w ResponseWriter, req *Request) {
+			w.Write([]byte("hello "))
+			w.Write([]byte("world"))
+		}),
+	}
+	addr := startRCONServer(t, s)
+
+	rc, err := Dial(addr, "blerg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	str, err := rc.ExecMulti("status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if str != "hello world" {
+		t.Fatalf("str = %q, want %q", str, "hello world")
+	}
+}
+
+// TestServerHandlerPanicRecovered makes sure a panicking Handler only takes
+// down the connection that triggered it, not the whole Server: other
+// connections (and later commands on other connections) must keep working.
+func TestServerHandlerPanicRecovered(t *testing.T) {
+	s := &Server{
+		Authenticator: func(password string) bool { return password == "blerg" },
+		Handler: HandlerFunc(func(w ResponseWriter, req *Request) {
+			if req.Body == "boom" {
+				panic("malformed command")
+			}
+			w.Write([]byte("ok"))
+		}),
+	}
+	addr := startRCONServer(t, s)
+
+	bad, err := Dial(addr, "blerg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bad.Close()
+
+	if _, err := bad.Write("boom"); err != nil {
+		t.Fatal(err)
+	}
+	// The handler panicked, so the server closed this connection instead of
+	// responding; Read should observe that rather than hang.
+	if _, _, err := bad.Read(); err == nil {
+		t.Fatal("expected an error reading after the handler panicked")
+	}
+
+	good, err := Dial(addr, "blerg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer good.Close()
+
+	if _, err := good.Write("status"); err != nil {
+		t.Fatal(err)
+	}
+	resp, _, err := good.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp != "ok" {
+		t.Fatalf("resp = %q, want %q", resp, "ok")
+	}
+}
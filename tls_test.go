@@ -0,0 +1,105 @@
+package rcon
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// selfSignedTLSConfig generates a throwaway self-signed cert/key pair for
+// 127.0.0.1, for use by both ends of a test-only TLS connection.
+func selfSignedTLSConfig(t *testing.T) *tls.Config {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+
+	pool := x509.NewCertPool()
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.AddCert(leaf)
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ServerName:   "127.0.0.1",
+	}
+}
+
+func TestEncryptedDial(t *testing.T) {
+	tlsConfig := selfSignedTLSConfig(t)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		buf := make([]byte, maxPackageSize)
+		_, err = conn.Read(buf)
+		if err != nil {
+			return
+		}
+
+		var packetSize, requestID, cmdType int32
+		var str []byte
+		b := bytes.NewBuffer(buf)
+		binary.Read(b, binary.LittleEndian, &packetSize)
+		binary.Read(b, binary.LittleEndian, &requestID)
+		binary.Read(b, binary.LittleEndian, &cmdType)
+		str, err = b.ReadBytes(0x00)
+		if err != nil {
+			return
+		}
+		if string(str[:len(str)-1]) != "blerg" {
+			requestID = -1
+		}
+
+		conn.Write(buildPackage(requestID, typeAuthResponse, []byte{}).Bytes())
+	}()
+
+	rc, err := EncryptedDial(listener.Addr().String(), "blerg", tlsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatal(err)
+	}
+}